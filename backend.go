@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Container is the subset of container metadata every StatsBackend needs to
+// report, independent of which runtime it talks to.
+type Container struct {
+	ID     string
+	Name   string
+	State  string
+	Labels map[string]string
+}
+
+// Sample is one decoded stats reading for a single container, or the error
+// from a failed reading.
+type Sample struct {
+	Metrics *ContainerMetrics
+	Err     error
+}
+
+// StatsBackend abstracts over the container runtime being scraped, so the
+// exporter can run against a Docker daemon, a Podman Docker-compat socket,
+// or plain containerd without the rest of the exporter caring which.
+type StatsBackend interface {
+	// List returns the containers currently known to the runtime.
+	List(ctx context.Context) ([]Container, error)
+
+	// Stats opens a stream of samples for id. The channel is closed once
+	// the stream ends, whether because ctx was cancelled or the backend
+	// has nothing more to send.
+	Stats(ctx context.Context, id string) (<-chan Sample, error)
+}
+
+// NewBackend constructs the StatsBackend named by --backend.
+func NewBackend(name string) (StatsBackend, error) {
+	switch name {
+	case "", "docker":
+		return NewDockerBackend()
+	case "podman":
+		return NewPodmanBackend()
+	case "containerd":
+		return NewContainerdBackend(*flagContainerdSocket, *flagContainerdNamespace)
+	default:
+		return nil, errors.Errorf("unknown --backend %q (want docker, podman or containerd)", name)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build linux
+
+package main
+
+import (
+	v1 "github.com/containerd/containerd/metrics/types/v1"
+	v2 "github.com/containerd/containerd/metrics/types/v2"
+	"github.com/pkg/errors"
+)
+
+// populateContainerdMetric fills c from a cgroup v1 (runc's CgroupStats) or
+// v2 (its successor) metrics payload, the two shapes containerd's Linux
+// shim reports depending on the host's cgroup version.
+func populateContainerdMetric(c *ContainerMetrics, data interface{}) error {
+	switch m := data.(type) {
+	case *v1.Metrics:
+		if m.CPU != nil && m.CPU.Usage != nil {
+			c.CPUStats.CPUUsage.TotalUsage = int(m.CPU.Usage.Total)
+			c.CPUStats.CPUUsage.PercpuUsage = make([]int, len(m.CPU.Usage.PerCPU))
+			for i, v := range m.CPU.Usage.PerCPU {
+				c.CPUStats.CPUUsage.PercpuUsage[i] = int(v)
+			}
+		}
+		if m.Memory != nil && m.Memory.Usage != nil {
+			c.MemoryStats.Usage = int(m.Memory.Usage.Usage)
+			c.MemoryStats.Limit = int(m.Memory.Usage.Limit)
+		}
+		if m.Pids != nil {
+			c.PidsStats.Current = int(m.Pids.Current)
+			c.PidsStats.Limit = int(m.Pids.Limit)
+		}
+		if m.Blkio != nil {
+			for _, entry := range m.Blkio.IoServiceBytesRecursive {
+				c.BlkioStats.IoServiceBytesRecursive = append(c.BlkioStats.IoServiceBytesRecursive, BlkioEntry{
+					Major: int(entry.Major),
+					Minor: int(entry.Minor),
+					Op:    entry.Op,
+					Value: int(entry.Value),
+				})
+			}
+		}
+	case *v2.Metrics:
+		if m.CPU != nil {
+			c.CPUStats.CPUUsage.TotalUsage = int(m.CPU.UsageUsec * 1000)
+		}
+		if m.Memory != nil {
+			c.MemoryStats.Usage = int(m.Memory.Usage)
+			c.MemoryStats.Limit = int(m.Memory.UsageLimit)
+		}
+		if m.Pids != nil {
+			c.PidsStats.Current = int(m.Pids.Current)
+			c.PidsStats.Limit = int(m.Pids.Limit)
+		}
+	default:
+		return errors.Errorf("unsupported containerd metrics payload %T", data)
+	}
+
+	return nil
+}
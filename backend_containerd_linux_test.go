@@ -0,0 +1,102 @@
+//go:build linux
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	cgroup1stats "github.com/containerd/cgroups/v3/cgroup1/stats"
+	"github.com/containerd/containerd/api/types"
+	v1 "github.com/containerd/containerd/metrics/types/v1"
+	v2 "github.com/containerd/containerd/metrics/types/v2"
+	"github.com/containerd/typeurl/v2"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// packMetric marshals v the way containerd's task.Metrics() does, wrapping
+// it in the *anypb.Any that types.Metric.Data actually carries.
+func packMetric(t *testing.T, v interface{}) *anypb.Any {
+	t.Helper()
+	any, err := typeurl.MarshalAny(v)
+	if err != nil {
+		t.Fatalf("MarshalAny: %v", err)
+	}
+	return &anypb.Any{TypeUrl: any.GetTypeUrl(), Value: any.GetValue()}
+}
+
+func TestComputeContainerdCPUPercentage(t *testing.T) {
+	prev := &ContainerMetrics{Read: time.Unix(0, 0)}
+	prev.CPUStats.CPUUsage.TotalUsage = 0
+
+	c := &ContainerMetrics{Read: time.Unix(0, 0).Add(time.Second)}
+	c.CPUStats.CPUUsage.TotalUsage = 1_000_000_000 // 1 second of usage, in ns
+	c.CPUStats.CPUUsage.PercpuUsage = []int{0, 0}  // 2 CPUs
+
+	// cpuDelta=1e9ns, timeDelta=1e9ns, numCPUs=2 -> (1e9/(1e9*2))*100: one
+	// fully-busy core out of two available is 50% of the container's total
+	// CPU capacity.
+	if got, want := computeContainerdCPUPercentage(c, prev), 50.0; got != want {
+		t.Errorf("computeContainerdCPUPercentage() = %v, want %v", got, want)
+	}
+}
+
+func TestComputeContainerdCPUPercentageNoPrev(t *testing.T) {
+	c := &ContainerMetrics{Read: time.Unix(0, 0)}
+	if got := computeContainerdCPUPercentage(c, nil); got != 0 {
+		t.Errorf("computeContainerdCPUPercentage(nil prev) = %v, want 0", got)
+	}
+}
+
+func TestConvertContainerdMetricV1(t *testing.T) {
+	data := packMetric(t, &v1.Metrics{
+		CPU: &v1.CPUStat{
+			Usage: &v1.CPUUsage{
+				Total:  1000,
+				PerCPU: []uint64{500, 500},
+			},
+		},
+		Memory: &v1.MemoryStat{
+			Usage: &cgroup1stats.MemoryEntry{Usage: 50, Limit: 100},
+		},
+		Pids: &v1.PidsStat{Current: 3, Limit: 10},
+	})
+
+	c, err := convertContainerdMetric(&types.Metric{Data: data})
+	if err != nil {
+		t.Fatalf("convertContainerdMetric: %v", err)
+	}
+
+	if c.CPUStats.CPUUsage.TotalUsage != 1000 {
+		t.Errorf("TotalUsage = %v, want 1000", c.CPUStats.CPUUsage.TotalUsage)
+	}
+	if len(c.CPUStats.CPUUsage.PercpuUsage) != 2 {
+		t.Errorf("PercpuUsage = %v, want 2 entries", c.CPUStats.CPUUsage.PercpuUsage)
+	}
+	if c.MemoryStats.Usage != 50 || c.MemoryStats.Limit != 100 {
+		t.Errorf("MemoryStats = %+v, want Usage=50 Limit=100", c.MemoryStats)
+	}
+	if c.PidsStats.Current != 3 || c.PidsStats.Limit != 10 {
+		t.Errorf("PidsStats = %+v, want Current=3 Limit=10", c.PidsStats)
+	}
+}
+
+func TestConvertContainerdMetricV2(t *testing.T) {
+	data := packMetric(t, &v2.Metrics{
+		CPU:    &v2.CPUStat{UsageUsec: 1000},
+		Memory: &v2.MemoryStat{Usage: 50, UsageLimit: 100},
+		Pids:   &v2.PidsStat{Current: 3, Limit: 10},
+	})
+
+	c, err := convertContainerdMetric(&types.Metric{Data: data})
+	if err != nil {
+		t.Fatalf("convertContainerdMetric: %v", err)
+	}
+
+	if want := 1000 * 1000; c.CPUStats.CPUUsage.TotalUsage != want {
+		t.Errorf("TotalUsage = %v, want %v", c.CPUStats.CPUUsage.TotalUsage, want)
+	}
+	if c.MemoryStats.Usage != 50 || c.MemoryStats.Limit != 100 {
+		t.Errorf("MemoryStats = %+v, want Usage=50 Limit=100", c.MemoryStats)
+	}
+}
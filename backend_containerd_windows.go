@@ -0,0 +1,37 @@
+//go:build windows
+
+package main
+
+import (
+	runhcsstats "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/stats"
+	"github.com/pkg/errors"
+)
+
+// populateContainerdMetric fills c from the runhcs shim's Statistics
+// payload, the shape containerd's Windows shim reports. There is no
+// per-device blkio or pids breakdown in this payload, so BlkioStats and
+// PidsStats are left zero-valued, same as the Docker/Podman Windows path in
+// moby.go.
+func populateContainerdMetric(c *ContainerMetrics, data interface{}) error {
+	m, ok := data.(*runhcsstats.Statistics)
+	if !ok {
+		return errors.Errorf("unsupported containerd metrics payload %T", data)
+	}
+
+	win := m.GetWindows()
+	if win == nil {
+		return errors.Errorf("containerd metrics payload has no Windows statistics (container may be a Linux-on-Hyper-V pod)")
+	}
+
+	if win.Processor != nil {
+		// 100ns units, same as CPUUsage elsewhere (see computeWindowsCPUAndMemory).
+		c.CPUStats.CPUUsage.TotalUsage = int(win.Processor.TotalRuntimeNS / 100)
+	}
+	if win.Memory != nil {
+		c.MemoryStats.CommitBytes = int64(win.Memory.MemoryUsageCommitBytes)
+		c.MemoryStats.CommitPeakBytes = int64(win.Memory.MemoryUsageCommitPeakBytes)
+		c.MemoryStats.PrivateWorkingSet = int64(win.Memory.MemoryUsagePrivateWorkingSetBytes)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+//go:build windows
+
+package main
+
+import (
+	"testing"
+
+	runhcsstats "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/stats"
+	"github.com/containerd/containerd/api/types"
+	"github.com/containerd/typeurl/v2"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func packWindowsMetric(t *testing.T, v interface{}) *anypb.Any {
+	t.Helper()
+	any, err := typeurl.MarshalAny(v)
+	if err != nil {
+		t.Fatalf("MarshalAny: %v", err)
+	}
+	return &anypb.Any{TypeUrl: any.GetTypeUrl(), Value: any.GetValue()}
+}
+
+func TestConvertContainerdMetricWindows(t *testing.T) {
+	data := packWindowsMetric(t, &runhcsstats.Statistics{
+		Container: &runhcsstats.Statistics_Windows{
+			Windows: &runhcsstats.WindowsContainerStatistics{
+				Processor: &runhcsstats.WindowsContainerProcessorStatistics{
+					TotalRuntimeNS: 100_000, // 1000 in 100ns units
+				},
+				Memory: &runhcsstats.WindowsContainerMemoryStatistics{
+					MemoryUsageCommitBytes:            50,
+					MemoryUsageCommitPeakBytes:        100,
+					MemoryUsagePrivateWorkingSetBytes: 25,
+				},
+			},
+		},
+	})
+
+	c, err := convertContainerdMetric(&types.Metric{Data: data})
+	if err != nil {
+		t.Fatalf("convertContainerdMetric: %v", err)
+	}
+
+	if c.CPUStats.CPUUsage.TotalUsage != 1000 {
+		t.Errorf("TotalUsage = %v, want 1000", c.CPUStats.CPUUsage.TotalUsage)
+	}
+	if c.MemoryStats.CommitBytes != 50 || c.MemoryStats.CommitPeakBytes != 100 || c.MemoryStats.PrivateWorkingSet != 25 {
+		t.Errorf("MemoryStats = %+v, want CommitBytes=50 CommitPeakBytes=100 PrivateWorkingSet=25", c.MemoryStats)
+	}
+}
+
+func TestConvertContainerdMetricWindowsNoWindowsStats(t *testing.T) {
+	data := packWindowsMetric(t, &runhcsstats.Statistics{})
+
+	if _, err := convertContainerdMetric(&types.Metric{Data: data}); err == nil {
+		t.Error("convertContainerdMetric() with no Windows statistics: want error, got nil")
+	}
+}
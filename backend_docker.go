@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// dockerBackend implements StatsBackend against a Docker-API-compatible
+// socket. Podman's compat layer speaks the same API (just on a different
+// socket), so this one type backs both the "docker" and "podman"
+// --backend values.
+type dockerBackend struct {
+	cli *client.Client
+}
+
+// NewDockerBackend dials the Docker daemon using the usual DOCKER_HOST/
+// DOCKER_* environment variables.
+func NewDockerBackend() (*dockerBackend, error) {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating Docker client")
+	}
+	return &dockerBackend{cli: cli}, nil
+}
+
+// NewPodmanBackend dials Podman's Docker-compatible API socket, which for a
+// rootless Podman defaults to $XDG_RUNTIME_DIR/podman/podman.sock.
+func NewPodmanBackend() (*dockerBackend, error) {
+	sock := "unix://" + os.Getenv("XDG_RUNTIME_DIR") + "/podman/podman.sock"
+
+	cli, err := client.NewClientWithOpts(client.WithHost(sock), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error creating Podman client for %s", sock)
+	}
+	return &dockerBackend{cli: cli}, nil
+}
+
+// Close implements io.Closer, used by Exporter.Close.
+func (b *dockerBackend) Close() error {
+	return b.cli.Close()
+}
+
+func (b *dockerBackend) List(ctx context.Context) ([]Container, error) {
+	containers, err := b.cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "Error obtaining container listing")
+	}
+
+	out := make([]Container, 0, len(containers))
+	for _, c := range containers {
+		if len(c.Names) == 0 {
+			continue
+		}
+		out = append(out, Container{
+			ID:     c.ID,
+			Name:   c.Names[0][1:],
+			State:  c.State,
+			Labels: c.Labels,
+		})
+	}
+	return out, nil
+}
+
+// Stats opens a long-lived streaming decoder against ContainerStats(ctx,
+// id, true), the same approach the Docker CLI's containerStats.Collect
+// uses, and decodes each JSON sample into a Sample on the returned channel.
+func (b *dockerBackend) Stats(ctx context.Context, id string) (<-chan Sample, error) {
+	p := detectPlatform(ctx, *b.cli)
+
+	stats, err := b.cli.ContainerStats(ctx, id, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error opening streaming stats for %s", id)
+	}
+
+	ch := make(chan Sample, 1)
+
+	go func() {
+		defer close(ch)
+		defer stats.Body.Close()
+
+		s := bufio.NewScanner(stats.Body)
+		var prev *ContainerMetrics
+
+		for s.Scan() {
+			var c *ContainerMetrics
+
+			if err := json.Unmarshal(s.Bytes(), &c); err != nil {
+				ch <- Sample{Err: errors.Wrapf(err, "Could not unmarshal the response from the docker engine for container %s", id)}
+				continue
+			}
+
+			computeDerivedMetrics(c, prev, p)
+			prev = c
+			ch <- Sample{Metrics: c}
+		}
+
+		if s.Err() != nil && ctx.Err() == nil {
+			ch <- Sample{Err: errors.Wrapf(s.Err(), "Error handling Stats.body from Docker engine for %s", id)}
+		}
+	}()
+
+	return ch, nil
+}
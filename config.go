@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+// Flags and environment variables for FilterConfig, named after telegraf's
+// docker input since that's the include/exclude convention users already
+// know from scoping Docker collectors to a subset of containers.
+var (
+	flagContainerNameInclude  = flag.String("container_name_include", "", "Comma-separated glob list of container names to collect stats for (default: all)")
+	flagContainerNameExclude  = flag.String("container_name_exclude", "", "Comma-separated glob list of container names to exclude")
+	flagContainerStateInclude = flag.String("container_state_include", "", "Comma-separated list of container states to collect stats for (default: running)")
+	flagDockerLabelInclude    = flag.String("docker_label_include", "", "Comma-separated glob list of container label keys to expose as metric labels (default: all)")
+	flagDockerLabelExclude    = flag.String("docker_label_exclude", "", "Comma-separated glob list of container label keys to exclude")
+
+	flagBackend             = flag.String("backend", "docker", "Which container runtime to collect stats from: docker, podman or containerd")
+	flagContainerdSocket    = flag.String("containerd_socket", "/run/containerd/containerd.sock", "Path to the containerd socket (only used with -backend=containerd)")
+	flagContainerdNamespace = flag.String("containerd_namespace", "default", "containerd namespace to list containers in (only used with -backend=containerd)")
+
+	flagListenAddress = flag.String("listen_address", ":9104", "Address to serve /metrics on")
+)
+
+// FilterConfigFromFlags builds a FilterConfig from the flags above, falling
+// back to the like-named environment variable (upper-cased) for any flag
+// left at its zero value, so the exporter can be configured the same way in
+// a container with no way to pass flags.
+func FilterConfigFromFlags() FilterConfig {
+	stateInclude := splitList(valueOrEnv(*flagContainerStateInclude, "CONTAINER_STATE_INCLUDE"))
+	if len(stateInclude) == 0 {
+		stateInclude = []string{"running"}
+	}
+
+	return FilterConfig{
+		NameInclude:  splitList(valueOrEnv(*flagContainerNameInclude, "CONTAINER_NAME_INCLUDE")),
+		NameExclude:  splitList(valueOrEnv(*flagContainerNameExclude, "CONTAINER_NAME_EXCLUDE")),
+		StateInclude: stateInclude,
+		LabelInclude: splitList(valueOrEnv(*flagDockerLabelInclude, "DOCKER_LABEL_INCLUDE")),
+		LabelExclude: splitList(valueOrEnv(*flagDockerLabelExclude, "DOCKER_LABEL_EXCLUDE")),
+	}
+}
+
+func valueOrEnv(flagValue, envName string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return os.Getenv(envName)
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	var list []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
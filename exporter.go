@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// containerCache holds the most recent decoded sample for a single
+// container, plus the one before it. The exporter does not read prev:
+// network byte rates are derived by the backend between consecutive stream
+// samples, before a sample reaches the cache. It is guarded by its own mutex rather than the
+// Exporter's, so a scrape for one container never blocks on another.
+type containerCache struct {
+	mu     sync.Mutex
+	latest *ContainerMetrics
+	prev   *ContainerMetrics
+}
+
+func (c *containerCache) set(cm *ContainerMetrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prev = c.latest
+	c.latest = cm
+}
+
+func (c *containerCache) get() *ContainerMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.latest
+}
+
+// containerStream is one long-lived per-container streaming decoder: its
+// cancel func lets reconcileStreams evict it once the container has
+// exited or disappeared from the listing.
+type containerStream struct {
+	cache  containerCache
+	cancel context.CancelFunc
+}
+
+// Exporter collects container stats through a StatsBackend and exposes them
+// as Prometheus metrics. The backend is dialed once and reused for the
+// exporter's whole lifetime rather than per scrape.
+type Exporter struct {
+	backend StatsBackend
+
+	// filter scopes which containers are collected and which of their
+	// labels are surfaced as metric labels.
+	filter FilterConfig
+
+	streamsMu sync.Mutex
+	streams   map[string]*containerStream
+
+	containerLabel   *prometheus.GaugeVec
+	cpuPercentage    *prometheus.GaugeVec
+	memoryUsage      *prometheus.GaugeVec
+	memoryLimit      *prometheus.GaugeVec
+	memoryPercentage *prometheus.GaugeVec
+	networkRxBytes   *prometheus.GaugeVec
+	networkTxBytes   *prometheus.GaugeVec
+	networkRxRate    *prometheus.GaugeVec
+	networkTxRate    *prometheus.GaugeVec
+	blkioBytes       *prometheus.GaugeVec
+	blkioOps         *prometheus.GaugeVec
+	pids             *prometheus.GaugeVec
+	pidsLimit        *prometheus.GaugeVec
+}
+
+// NewExporter creates an Exporter over the given StatsBackend (see
+// NewBackend). filter scopes which containers are collected and which of
+// their labels are surfaced as metric labels.
+func NewExporter(backend StatsBackend, filter FilterConfig) *Exporter {
+	labels := []string{"id", "name"}
+	netLabels := []string{"id", "name", "interface"}
+	blkioLabels := []string{"id", "name", "device", "op"}
+
+	return &Exporter{
+		backend: backend,
+		filter:  filter,
+		streams: make(map[string]*containerStream),
+
+		containerLabel: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "label_info",
+			Help:      "Always 1; exposes a container's Docker labels (as passed through docker_label_include/exclude) as metric labels",
+		}, []string{"id", "name", "label_name", "label_value"}),
+		cpuPercentage: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "cpu_percentage",
+			Help:      "CPU usage as a percentage; for Linux Docker/Podman samples 100 is one full CPU, for Windows and containerd samples 100 is all of the host's CPUs",
+		}, labels),
+		memoryUsage: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "memory_usage_bytes",
+			Help:      "Current memory usage in bytes",
+		}, labels),
+		memoryLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "memory_limit_bytes",
+			Help:      "Memory limit in bytes",
+		}, labels),
+		memoryPercentage: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "memory_percentage",
+			Help:      "Memory usage as a percentage of the memory limit",
+		}, labels),
+		networkRxBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "network_rx_bytes_total",
+			Help:      "Total bytes received on a network interface",
+		}, netLabels),
+		networkTxBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "network_tx_bytes_total",
+			Help:      "Total bytes sent on a network interface",
+		}, netLabels),
+		networkRxRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "network_rx_bytes_per_second",
+			Help:      "Receive rate on a network interface, derived from the previous sample",
+		}, netLabels),
+		networkTxRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "network_tx_bytes_per_second",
+			Help:      "Transmit rate on a network interface, derived from the previous sample",
+		}, netLabels),
+		blkioBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "blkio_bytes_total",
+			Help:      "Total bytes transferred to/from a block device, by device and operation",
+		}, blkioLabels),
+		blkioOps: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "blkio_ops_total",
+			Help:      "Total I/O operations against a block device, by device and operation",
+		}, blkioLabels),
+		pids: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "pids",
+			Help:      "Current number of PIDs in the container's cgroup",
+		}, labels),
+		pidsLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "container",
+			Name:      "pids_limit",
+			Help:      "Maximum number of PIDs allowed in the container's cgroup",
+		}, labels),
+	}
+}
+
+// Close stops every running stream and closes the backend, if it supports
+// closing. Call it when the exporter is being shut down.
+func (e *Exporter) Close() error {
+	e.streamsMu.Lock()
+	for id, st := range e.streams {
+		st.cancel()
+		delete(e.streams, id)
+	}
+	e.streamsMu.Unlock()
+
+	if closer, ok := e.backend.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	e.containerLabel.Describe(ch)
+	e.cpuPercentage.Describe(ch)
+	e.memoryUsage.Describe(ch)
+	e.memoryLimit.Describe(ch)
+	e.memoryPercentage.Describe(ch)
+	e.networkRxBytes.Describe(ch)
+	e.networkTxBytes.Describe(ch)
+	e.networkRxRate.Describe(ch)
+	e.networkTxRate.Describe(ch)
+	e.blkioBytes.Describe(ch)
+	e.blkioOps.Describe(ch)
+	e.pids.Describe(ch)
+	e.pidsLimit.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+
+	containers, err := e.backend.List(ctx)
+	if err == nil {
+		containers = filterContainers(containers, e.filter)
+		e.reconcileStreams(containers)
+	}
+
+	metrics := make([]*ContainerMetrics, 0, len(containers))
+	for _, c := range containers {
+		metrics = append(metrics, e.cachedContainerMetrics(c.ID))
+	}
+
+	for _, cm := range metrics {
+		if cm.Error != nil {
+			continue
+		}
+
+		for k, v := range cm.Labels {
+			e.containerLabel.WithLabelValues(cm.ID, cm.Name, k, v).Set(1)
+		}
+
+		e.cpuPercentage.WithLabelValues(cm.ID, cm.Name).Set(cm.CPUPercentage)
+		e.memoryUsage.WithLabelValues(cm.ID, cm.Name).Set(float64(cm.MemoryStats.Usage))
+		e.memoryLimit.WithLabelValues(cm.ID, cm.Name).Set(float64(cm.MemoryStats.Limit))
+		e.memoryPercentage.WithLabelValues(cm.ID, cm.Name).Set(cm.MemoryPercentage)
+
+		for iface, stats := range cm.NetIntefaces {
+			e.networkRxBytes.WithLabelValues(cm.ID, cm.Name, iface).Set(float64(stats.RxBytes))
+			e.networkTxBytes.WithLabelValues(cm.ID, cm.Name, iface).Set(float64(stats.TxBytes))
+		}
+		for iface, rate := range cm.NetworkRxRate {
+			e.networkRxRate.WithLabelValues(cm.ID, cm.Name, iface).Set(rate)
+		}
+		for iface, rate := range cm.NetworkTxRate {
+			e.networkTxRate.WithLabelValues(cm.ID, cm.Name, iface).Set(rate)
+		}
+
+		for _, entry := range cm.BlkioStats.IoServiceBytesRecursive {
+			e.blkioBytes.WithLabelValues(cm.ID, cm.Name, entry.Device(), entry.Op).Set(float64(entry.Value))
+		}
+		for _, entry := range cm.BlkioStats.IoServicedRecursive {
+			e.blkioOps.WithLabelValues(cm.ID, cm.Name, entry.Device(), entry.Op).Set(float64(entry.Value))
+		}
+
+		e.pids.WithLabelValues(cm.ID, cm.Name).Set(float64(cm.PidsStats.Current))
+		e.pidsLimit.WithLabelValues(cm.ID, cm.Name).Set(float64(cm.PidsStats.Limit))
+	}
+
+	e.containerLabel.Collect(ch)
+	e.cpuPercentage.Collect(ch)
+	e.memoryUsage.Collect(ch)
+	e.memoryLimit.Collect(ch)
+	e.memoryPercentage.Collect(ch)
+	e.networkRxBytes.Collect(ch)
+	e.networkTxBytes.Collect(ch)
+	e.networkRxRate.Collect(ch)
+	e.networkTxRate.Collect(ch)
+	e.blkioBytes.Collect(ch)
+	e.blkioOps.Collect(ch)
+	e.pids.Collect(ch)
+	e.pidsLimit.Collect(ch)
+}
+
+// reconcileStreams starts a stream for every container in containers that
+// doesn't already have one running, and evicts (cancels and drops) the
+// stream for any previously-seen container that is no longer in the
+// listing, e.g. because it exited or was removed.
+func (e *Exporter) reconcileStreams(containers []Container) {
+	seen := make(map[string]bool, len(containers))
+
+	e.streamsMu.Lock()
+
+	for _, c := range containers {
+		seen[c.ID] = true
+
+		if _, ok := e.streams[c.ID]; ok {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		st := &containerStream{cancel: cancel}
+		e.streams[c.ID] = st
+
+		go e.runStream(ctx, c, st)
+	}
+
+	for id, st := range e.streams {
+		if seen[id] {
+			continue
+		}
+		st.cancel()
+		delete(e.streams, id)
+	}
+
+	e.streamsMu.Unlock()
+}
+
+// cachedContainerMetrics returns the latest cached sample for id. It
+// assumes reconcileStreams has already started a stream for id; if no
+// sample has arrived yet (or the container vanished between the listing and
+// this call) it reports that rather than blocking the scrape.
+func (e *Exporter) cachedContainerMetrics(id string) *ContainerMetrics {
+	e.streamsMu.Lock()
+	st, ok := e.streams[id]
+	e.streamsMu.Unlock()
+
+	if ok {
+		if cm := st.cache.get(); cm != nil {
+			return cm
+		}
+	}
+
+	return &ContainerMetrics{
+		ID:    id,
+		Error: errors.Errorf("no stats sample yet for container %s", id),
+	}
+}
+
+// runStream drains the backend's sample channel for c into st.cache for as
+// long as ctx stays alive. Cancelling ctx (via reconcileStreams evicting the
+// container) is what ends the goroutine, whether the backend notices the
+// cancellation itself or the channel simply closes.
+func (e *Exporter) runStream(ctx context.Context, c Container, st *containerStream) {
+	samples, err := e.backend.Stats(ctx, c.ID)
+	if err != nil {
+		st.cache.set(&ContainerMetrics{
+			ID:    c.ID,
+			Name:  c.Name,
+			Error: errors.Wrapf(err, "Error opening stats stream for %s", c.ID),
+		})
+		// Stats() failed before handing back a channel, e.g. a transient
+		// dial error; drop the entry so the next reconcileStreams retries
+		// instead of leaving the container stuck on this one-time error for
+		// as long as it stays listed.
+		e.evictStream(c.ID, st)
+		return
+	}
+
+	for sample := range samples {
+		if sample.Err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			st.cache.set(&ContainerMetrics{ID: c.ID, Name: c.Name, Error: sample.Err})
+			continue
+		}
+
+		m := sample.Metrics
+		m.ID = c.ID
+		m.Name = c.Name
+		m.Labels = c.Labels
+		st.cache.set(m)
+	}
+
+	// The channel closed on its own, not because we cancelled ctx; evict so
+	// the next reconcileStreams retries rather than leaving the container
+	// stuck on its last cached sample (or error) forever.
+	if ctx.Err() == nil {
+		e.evictStream(c.ID, st)
+	}
+}
+
+// evictStream drops id's entry from e.streams, but only if st is still the
+// current stream for it (reconcileStreams may have already evicted and
+// replaced it), so the next scrape's reconcileStreams starts a fresh one.
+func (e *Exporter) evictStream(id string, st *containerStream) {
+	e.streamsMu.Lock()
+	if e.streams[id] == st {
+		delete(e.streams, id)
+	}
+	e.streamsMu.Unlock()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend is a StatsBackend test double whose container listing and
+// per-container sample channels are entirely test-controlled, so
+// reconcileStreams/runStream's eviction and retry behavior can be driven
+// deterministically without a real Docker/containerd socket.
+type fakeBackend struct {
+	mu         sync.Mutex
+	containers []Container
+	chans      map[string]chan Sample
+	ctxs       map[string]context.Context
+	statsCalls map[string]int
+}
+
+func newFakeBackend(containers ...Container) *fakeBackend {
+	return &fakeBackend{
+		containers: containers,
+		chans:      make(map[string]chan Sample),
+		ctxs:       make(map[string]context.Context),
+		statsCalls: make(map[string]int),
+	}
+}
+
+func (f *fakeBackend) List(ctx context.Context) ([]Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]Container, len(f.containers))
+	copy(out, f.containers)
+	return out, nil
+}
+
+func (f *fakeBackend) setContainers(containers ...Container) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.containers = containers
+}
+
+func (f *fakeBackend) Stats(ctx context.Context, id string) (<-chan Sample, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.statsCalls[id]++
+	ch := make(chan Sample, 1)
+	ch <- Sample{Metrics: &ContainerMetrics{}}
+	f.chans[id] = ch
+	f.ctxs[id] = ctx
+	return ch, nil
+}
+
+// endStream closes id's sample channel as if the backend's stream ended on
+// its own, without the caller having cancelled ctx.
+func (f *fakeBackend) endStream(id string) {
+	f.mu.Lock()
+	ch := f.chans[id]
+	f.mu.Unlock()
+	close(ch)
+}
+
+func (f *fakeBackend) contextFor(id string) context.Context {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ctxs[id]
+}
+
+func (f *fakeBackend) callCount(id string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.statsCalls[id]
+}
+
+// waitUntil polls cond until it returns true or timeout elapses, failing t
+// if it never does. The stream lifecycle under test runs on its own
+// goroutine, so assertions about its side effects can't be made
+// synchronously with the call that triggers them.
+func waitUntil(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if cond() {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within %s", timeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReconcileStreamsEvictsDisappearedContainer(t *testing.T) {
+	fb := newFakeBackend(Container{ID: "a", Name: "a"})
+	e := NewExporter(fb, FilterConfig{})
+
+	containers, err := fb.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	e.reconcileStreams(containers)
+
+	waitUntil(t, time.Second, func() bool { return fb.callCount("a") == 1 })
+
+	ctx := fb.contextFor("a")
+
+	// "a" disappears from the listing, e.g. it was removed.
+	fb.setContainers()
+	e.reconcileStreams(nil)
+
+	e.streamsMu.Lock()
+	_, ok := e.streams["a"]
+	e.streamsMu.Unlock()
+	if ok {
+		t.Fatal("reconcileStreams() left a stream entry for a container no longer listed")
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("reconcileStreams() did not cancel the evicted container's stream context")
+	}
+}
+
+func TestRunStreamRetriesAfterStreamEndsOnItsOwn(t *testing.T) {
+	fb := newFakeBackend(Container{ID: "a", Name: "a"})
+	e := NewExporter(fb, FilterConfig{})
+
+	containers, err := fb.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	e.reconcileStreams(containers)
+
+	waitUntil(t, time.Second, func() bool { return fb.callCount("a") == 1 })
+
+	// The stream ends on its own (not because reconcileStreams cancelled
+	// it), e.g. the backend's stats stream hit EOF.
+	fb.endStream("a")
+
+	waitUntil(t, time.Second, func() bool {
+		e.streamsMu.Lock()
+		defer e.streamsMu.Unlock()
+		_, ok := e.streams["a"]
+		return !ok
+	})
+
+	// The container is still listed, so the next scrape should start a
+	// fresh stream rather than leaving it stuck on the old one.
+	e.reconcileStreams(containers)
+
+	waitUntil(t, time.Second, func() bool { return fb.callCount("a") == 2 })
+}
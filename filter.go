@@ -0,0 +1,85 @@
+package main
+
+import "path/filepath"
+
+// FilterConfig controls which containers the Exporter collects stats for,
+// and which of their labels are surfaced as metric labels. It
+// mirrors telegraf's docker input: name/label glob include+exclude lists,
+// plus a container state include list.
+type FilterConfig struct {
+	NameInclude  []string
+	NameExclude  []string
+	StateInclude []string
+	LabelInclude []string
+	LabelExclude []string
+}
+
+// DefaultFilterConfig is the exporter's default filtering: only running
+// containers, with no name/label restriction.
+func DefaultFilterConfig() FilterConfig {
+	return FilterConfig{
+		StateInclude: []string{"running"},
+	}
+}
+
+// matchesName reports whether name passes the include/exclude glob lists.
+// An empty include list matches everything; an empty exclude list excludes
+// nothing.
+func (f FilterConfig) matchesName(name string) bool {
+	if globListMatches(f.NameExclude, name) {
+		return false
+	}
+	return len(f.NameInclude) == 0 || globListMatches(f.NameInclude, name)
+}
+
+// matchesState reports whether a container's state passes StateInclude. An
+// empty list matches every state.
+func (f FilterConfig) matchesState(state string) bool {
+	return len(f.StateInclude) == 0 || globListMatches(f.StateInclude, state)
+}
+
+// matchingLabels returns the subset of labels that pass the
+// LabelInclude/LabelExclude glob lists on the label key, for attaching to
+// Prometheus metrics.
+func (f FilterConfig) matchingLabels(labels map[string]string) map[string]string {
+	matched := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if globListMatches(f.LabelExclude, k) {
+			continue
+		}
+		if len(f.LabelInclude) > 0 && !globListMatches(f.LabelInclude, k) {
+			continue
+		}
+		matched[k] = v
+	}
+	return matched
+}
+
+// filterContainers applies the name, state and label glob lists to a
+// backend's container listing, regardless of which StatsBackend produced
+// it.
+func filterContainers(containers []Container, f FilterConfig) []Container {
+	filtered := containers[:0]
+	for _, c := range containers {
+		if !f.matchesName(c.Name) {
+			continue
+		}
+		if !f.matchesState(c.State) {
+			continue
+		}
+		c.Labels = f.matchingLabels(c.Labels)
+		filtered = append(filtered, c)
+	}
+	return filtered
+}
+
+// globListMatches reports whether value matches any glob pattern in
+// patterns, per filepath.Match (so e.g. "web-*" matches "web-frontend").
+func globListMatches(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
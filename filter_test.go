@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterConfigMatchesName(t *testing.T) {
+	cases := []struct {
+		name string
+		f    FilterConfig
+		in   string
+		want bool
+	}{
+		{"no lists matches everything", FilterConfig{}, "web-1", true},
+		{"include glob matches", FilterConfig{NameInclude: []string{"web-*"}}, "web-1", true},
+		{"include glob excludes non-matches", FilterConfig{NameInclude: []string{"web-*"}}, "db-1", false},
+		{"exclude glob wins over include", FilterConfig{NameInclude: []string{"*"}, NameExclude: []string{"db-*"}}, "db-1", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.f.matchesName(tc.in); got != tc.want {
+				t.Errorf("matchesName(%q) = %v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterConfigMatchesState(t *testing.T) {
+	f := DefaultFilterConfig()
+
+	if !f.matchesState("running") {
+		t.Error("matchesState(running) = false, want true for the default filter")
+	}
+	if f.matchesState("exited") {
+		t.Error("matchesState(exited) = true, want false for the default filter")
+	}
+}
+
+func TestFilterConfigMatchingLabels(t *testing.T) {
+	f := FilterConfig{
+		LabelInclude: []string{"app.*"},
+		LabelExclude: []string{"app.secret"},
+	}
+
+	labels := map[string]string{
+		"app.name":   "web",
+		"app.secret": "shh",
+		"unrelated":  "x",
+	}
+
+	want := map[string]string{"app.name": "web"}
+
+	if got := f.matchingLabels(labels); !reflect.DeepEqual(got, want) {
+		t.Errorf("matchingLabels() = %v, want %v", got, want)
+	}
+}
+
+func TestFilterContainers(t *testing.T) {
+	f := FilterConfig{StateInclude: []string{"running"}}
+
+	containers := []Container{
+		{ID: "1", Name: "web-1", State: "running"},
+		{ID: "2", Name: "web-2", State: "exited"},
+	}
+
+	got := filterContainers(containers, f)
+
+	if len(got) != 1 || got[0].ID != "1" {
+		t.Errorf("filterContainers() = %v, want only the running container", got)
+	}
+}
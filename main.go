@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func main() {
+	flag.Parse()
+
+	backend, err := NewBackend(*flagBackend)
+	if err != nil {
+		log.Fatalf("Error creating %s backend: %v", *flagBackend, err)
+	}
+
+	exporter := NewExporter(backend, FilterConfigFromFlags())
+	defer exporter.Close()
+
+	prometheus.MustRegister(exporter)
+
+	http.Handle("/metrics", promhttp.Handler())
+	log.Printf("Serving metrics on %s/metrics", *flagListenAddress)
+	log.Fatal(http.ListenAndServe(*flagListenAddress, nil))
+}
@@ -1,20 +1,32 @@
 package main
 
 import (
-	"bufio"
-	"context"
-	"encoding/json"
-
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
-	"github.com/pkg/errors"
+	"fmt"
+	"time"
 )
 
+// BlkioEntry is one major:minor/op entry from blkio_stats, e.g. the bytes
+// read from a given block device.
+type BlkioEntry struct {
+	Major int    `json:"major"`
+	Minor int    `json:"minor"`
+	Op    string `json:"op"`
+	Value int    `json:"value"`
+}
+
+// Device renders the entry's major:minor pair the way the kernel and
+// `lsblk` do, for use as a metric label.
+func (b BlkioEntry) Device() string {
+	return fmt.Sprintf("%d:%d", b.Major, b.Minor)
+}
+
 // ContainerMetrics is used to track the core JSON response from the stats API
 type ContainerMetrics struct {
 	ID           string
 	Name         string
 	Error        error
+	Labels       map[string]string
+	Read         time.Time `json:"read"`
 	NetIntefaces map[string]struct {
 		RxBytes   int `json:"rx_bytes"`
 		RxDropped int `json:"rx_dropped"`
@@ -26,8 +38,13 @@ type ContainerMetrics struct {
 		TxPackets int `json:"tx_packets"`
 	} `json:"networks"`
 	MemoryStats struct {
+		// Linux
 		Usage int `json:"usage"`
 		Limit int `json:"limit"`
+		// Windows
+		CommitBytes       int64 `json:"commitbytes"`
+		CommitPeakBytes   int64 `json:"commitpeakbytes"`
+		PrivateWorkingSet int64 `json:"privateworkingset"`
 	} `json:"memory_stats"`
 	CPUStats struct {
 		CPUUsage struct {
@@ -47,109 +64,109 @@ type ContainerMetrics struct {
 		} `json:"cpu_usage"`
 		SystemCPUUsage int64 `json:"system_cpu_usage"`
 	} `json:"precpu_stats"`
+	// NumProcs is only populated by Windows daemons, and stands in for the
+	// per-host CPU count that Linux samples give as len(percpu_usage).
+	NumProcs   int `json:"num_procs"`
+	BlkioStats struct {
+		IoServiceBytesRecursive []BlkioEntry `json:"io_service_bytes_recursive"`
+		IoServicedRecursive     []BlkioEntry `json:"io_serviced_recursive"`
+	} `json:"blkio_stats"`
+	PidsStats struct {
+		Current int `json:"current"`
+		Limit   int `json:"limit"`
+	} `json:"pids_stats"`
+
+	// Derived fields, filled in once a sample has been decoded: by
+	// computeDerivedMetrics for Docker/Podman samples, and by the containerd
+	// backend's own Stats loop for containerd samples. They have no
+	// equivalent in the raw Docker API response.
+	CPUPercentage    float64
+	MemoryPercentage float64
+	NetworkRxRate    map[string]float64
+	NetworkTxRate    map[string]float64
 }
 
-func (e *Exporter) asyncRetrieveMetrics() ([]*ContainerMetrics, []error) {
-
-	var errs []error
-
-	// Create new docker API client for passed down to the async requests
-	cli, err := client.NewEnvClient()
-	if err != nil {
-		errs = append(errs, errors.Wrapf(err, "Error creating Docker client"))
-		return nil, errs
+// computeDerivedMetrics fills in CPUPercentage, MemoryPercentage and the
+// per-interface network byte rates for c, using the CPU/memory calculator
+// appropriate for p. prev is the previous sample seen for the same
+// container; it may be nil, in which case the rate fields are left empty.
+func computeDerivedMetrics(c *ContainerMetrics, prev *ContainerMetrics, p platform) {
+	// /info can be wrong or unavailable (e.g. proxied sockets); the sample
+	// itself is the ultimate source of truth for which schema it's in.
+	if p == platformLinux {
+		p = detectPlatformFromSample(c)
 	}
 
-	// Close the client after the execution
-	defer cli.Close()
-
-	// Obtain a list of running containers only
-	// Docker stats API won't return stats for containers not in the running state
-	containers, err := cli.ContainerList(context.Background(), types.ContainerListOptions{All: false})
-	if err != nil {
-		errs = append(errs, errors.Wrap(err, "Error obtaining container listing"))
-		return nil, errs
+	if p == platformWindows {
+		computeWindowsCPUAndMemory(c, prev)
+	} else {
+		computeLinuxCPUAndMemory(c, prev)
 	}
 
-	// Channels used to enable concurrent requests
-	ch := make(chan *ContainerMetrics, len(containers))
-	ContainerMetrics := []*ContainerMetrics{}
-
-	// Check that there are indeed containers running we can obtain stats for
-	if len(containers) == 0 {
-		errs = append(errs, errors.Wrap(err, "No Containers returned from Docker socket"))
-		return ContainerMetrics, errs
+	c.NetworkRxRate = map[string]float64{}
+	c.NetworkTxRate = map[string]float64{}
 
+	if prev == nil || prev.Read.IsZero() || c.Read.IsZero() {
+		return
 	}
 
-	// range through the returned containers to obtain the statistics
-	// Done due to there not yet being a '--all' option for the cli.ContainerMetrics function in the engine
-	for _, c := range containers {
-
-		go func(cli *client.Client, id, name string) {
-			retrieveContainerMetrics(*cli, id, name, ch)
-
-		}(cli, c.ID, c.Names[0][1:])
-
+	interval := c.Read.Sub(prev.Read).Seconds()
+	if interval <= 0 {
+		return
 	}
 
-	for {
-		select {
-		case r := <-ch:
-
-			if r.Error != nil {
-				errs = append(errs, errors.Wrapf(err, "Error processing stats"))
-				break
-			}
-
-			ContainerMetrics = append(ContainerMetrics, r)
-
-			if len(ContainerMetrics) == len(containers) {
-				return ContainerMetrics, nil
-			}
+	for iface, stats := range c.NetIntefaces {
+		prevStats, ok := prev.NetIntefaces[iface]
+		if !ok {
+			continue
 		}
-
+		c.NetworkRxRate[iface] = float64(stats.RxBytes-prevStats.RxBytes) / interval
+		c.NetworkTxRate[iface] = float64(stats.TxBytes-prevStats.TxBytes) / interval
 	}
-
 }
 
-func retrieveContainerMetrics(cli client.Client, id, name string, ch chan<- *ContainerMetrics) {
+// computeLinuxCPUAndMemory implements the cgroup-based CPU/memory
+// percentages used by Linux daemons: CPU% scales the container's share of
+// the host's CPU delta by the number of CPUs, and memory% is usage/limit.
+func computeLinuxCPUAndMemory(c *ContainerMetrics, prev *ContainerMetrics) {
+	cpuDelta := float64(c.CPUStats.CPUUsage.TotalUsage - c.PrecpuStats.CPUUsage.TotalUsage)
+	systemDelta := float64(c.CPUStats.SystemCPUUsage - c.PrecpuStats.SystemCPUUsage)
+
+	if systemDelta > 0 && cpuDelta > 0 {
+		numCPUs := float64(len(c.CPUStats.CPUUsage.PercpuUsage))
+		if numCPUs == 0 {
+			numCPUs = 1
+		}
+		c.CPUPercentage = (cpuDelta / systemDelta) * numCPUs * 100
+	}
 
-	// Used to append errors to for the containerstats and scan functions
-	var cm *ContainerMetrics
+	if c.MemoryStats.Limit > 0 {
+		c.MemoryPercentage = float64(c.MemoryStats.Usage) / float64(c.MemoryStats.Limit) * 100
+	}
+}
 
-	stats, err := cli.ContainerStats(context.Background(), id, false)
-	if err != nil {
-		cm.Error = errors.Wrapf(err, "Error obtaining container stats for %s, error: %v", id, err)
-		ch <- cm
+// computeWindowsCPUAndMemory implements the job-object based CPU/memory
+// percentages used by Windows daemons. There is no system_cpu_usage or
+// memory limit on Windows, so CPU% is scaled by wall-clock time and the
+// number of processors, and "memory" is reported as the process working
+// set rather than a percentage of a limit.
+func computeWindowsCPUAndMemory(c *ContainerMetrics, prev *ContainerMetrics) {
+	if prev == nil || prev.Read.IsZero() || c.Read.IsZero() {
 		return
 	}
 
-	s := bufio.NewScanner(stats.Body)
-
-	for s.Scan() {
-
-		var c *ContainerMetrics
-
-		err := json.Unmarshal(s.Bytes(), &c)
-		if err != nil {
-			c.Error = errors.Wrapf(err, "Could not unmarshal the response from the docker engine for container %s", id)
-			ch <- c
-			continue
-		}
-
-		// Set the container name and ID fields of the ContainerMetrics struct
-		// so we can correctly report on the container when looping through later
-		c.ID = id
-		c.Name = name
-
-		ch <- c
+	cpuDelta := float64(c.CPUStats.CPUUsage.TotalUsage - c.PrecpuStats.CPUUsage.TotalUsage)
+	timeDelta := float64(c.Read.Sub(prev.Read).Nanoseconds()) / 100 // 100ns units, same as CPUUsage
+	numProcs := float64(c.NumProcs)
+	if numProcs == 0 {
+		numProcs = 1
 	}
 
-	if s.Err() != nil {
-		cm.Error = errors.Wrapf(err, "Error handling Stats.body from Docker engine")
-		ch <- cm
-		return
+	if timeDelta > 0 && cpuDelta > 0 {
+		c.CPUPercentage = cpuDelta / (timeDelta * numProcs) * 100
 	}
 
+	// Windows has no memory limit to compute a percentage against; report
+	// private working set as the usage figure callers care about instead.
+	c.MemoryStats.Usage = int(c.MemoryStats.PrivateWorkingSet)
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeLinuxCPUAndMemory(t *testing.T) {
+	c := &ContainerMetrics{}
+	c.CPUStats.CPUUsage.TotalUsage = 300
+	c.CPUStats.CPUUsage.PercpuUsage = []int{1, 2}
+	c.CPUStats.SystemCPUUsage = 1200
+	c.PrecpuStats.CPUUsage.TotalUsage = 100
+	c.PrecpuStats.SystemCPUUsage = 1000
+	c.MemoryStats.Usage = 50
+	c.MemoryStats.Limit = 100
+
+	computeLinuxCPUAndMemory(c, nil)
+
+	// cpuDelta=200, systemDelta=200, numCPUs=2 -> (200/200)*2*100
+	if want := 200.0; c.CPUPercentage != want {
+		t.Errorf("CPUPercentage = %v, want %v", c.CPUPercentage, want)
+	}
+	if want := 50.0; c.MemoryPercentage != want {
+		t.Errorf("MemoryPercentage = %v, want %v", c.MemoryPercentage, want)
+	}
+}
+
+func TestComputeLinuxCPUAndMemoryNoDelta(t *testing.T) {
+	c := &ContainerMetrics{}
+	c.CPUStats.CPUUsage.TotalUsage = 100
+	c.CPUStats.SystemCPUUsage = 1000
+	c.PrecpuStats.CPUUsage.TotalUsage = 100
+	c.PrecpuStats.SystemCPUUsage = 1000
+
+	computeLinuxCPUAndMemory(c, nil)
+
+	if c.CPUPercentage != 0 {
+		t.Errorf("CPUPercentage = %v, want 0 when there's no usage delta", c.CPUPercentage)
+	}
+}
+
+func TestComputeWindowsCPUAndMemory(t *testing.T) {
+	prev := &ContainerMetrics{Read: time.Unix(0, 0)}
+
+	c := &ContainerMetrics{Read: time.Unix(0, 0).Add(time.Second)}
+	c.CPUStats.CPUUsage.TotalUsage = 10_000_000 // 1s of usage, in 100ns units
+	c.NumProcs = 1
+	c.MemoryStats.PrivateWorkingSet = 123
+
+	computeWindowsCPUAndMemory(c, prev)
+
+	if want := 100.0; c.CPUPercentage != want {
+		t.Errorf("CPUPercentage = %v, want %v", c.CPUPercentage, want)
+	}
+	if c.MemoryStats.Usage != 123 {
+		t.Errorf("MemoryStats.Usage = %v, want 123 (copied from PrivateWorkingSet)", c.MemoryStats.Usage)
+	}
+}
+
+func TestComputeDerivedMetricsNetworkRate(t *testing.T) {
+	prev := &ContainerMetrics{Read: time.Unix(0, 0)}
+	prev.NetIntefaces = map[string]struct {
+		RxBytes   int `json:"rx_bytes"`
+		RxDropped int `json:"rx_dropped"`
+		RxErrors  int `json:"rx_errors"`
+		RxPackets int `json:"rx_packets"`
+		TxBytes   int `json:"tx_bytes"`
+		TxDropped int `json:"tx_dropped"`
+		TxErrors  int `json:"tx_errors"`
+		TxPackets int `json:"tx_packets"`
+	}{
+		"eth0": {RxBytes: 100, TxBytes: 50},
+	}
+
+	c := &ContainerMetrics{Read: time.Unix(0, 0).Add(2 * time.Second)}
+	c.NetIntefaces = map[string]struct {
+		RxBytes   int `json:"rx_bytes"`
+		RxDropped int `json:"rx_dropped"`
+		RxErrors  int `json:"rx_errors"`
+		RxPackets int `json:"rx_packets"`
+		TxBytes   int `json:"tx_bytes"`
+		TxDropped int `json:"tx_dropped"`
+		TxErrors  int `json:"tx_errors"`
+		TxPackets int `json:"tx_packets"`
+	}{
+		"eth0": {RxBytes: 300, TxBytes: 150},
+	}
+
+	computeDerivedMetrics(c, prev, platformLinux)
+
+	if got, want := c.NetworkRxRate["eth0"], 100.0; got != want {
+		t.Errorf("NetworkRxRate[eth0] = %v, want %v", got, want)
+	}
+	if got, want := c.NetworkTxRate["eth0"], 50.0; got != want {
+		t.Errorf("NetworkTxRate[eth0] = %v, want %v", got, want)
+	}
+}
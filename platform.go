@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+
+	"github.com/docker/docker/client"
+)
+
+// platform identifies the stats JSON schema a container's Docker daemon
+// reports, since Linux and Windows daemons shape ContainerMetrics very
+// differently (see computeLinuxCPUAndMemory / computeWindowsCPUAndMemory).
+type platform int
+
+const (
+	platformLinux platform = iota
+	platformWindows
+)
+
+// detectPlatform asks the daemon what OS it is running. If the /info call
+// fails for any reason we default to Linux, the far more common case, and
+// rely on detectPlatformFromSample as a per-container fallback.
+func detectPlatform(ctx context.Context, cli client.Client) platform {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return platformLinux
+	}
+
+	if info.OSType == "windows" {
+		return platformWindows
+	}
+
+	return platformLinux
+}
+
+// detectPlatformFromSample guesses the platform from the shape of a single
+// decoded sample, for use when /info isn't available or reports Linux.
+// Windows samples carry no system_cpu_usage and no per-core CPU breakdown.
+func detectPlatformFromSample(c *ContainerMetrics) platform {
+	if c.CPUStats.SystemCPUUsage == 0 && len(c.CPUStats.CPUUsage.PercpuUsage) == 0 {
+		return platformWindows
+	}
+	return platformLinux
+}